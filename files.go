@@ -0,0 +1,213 @@
+//   Copyright 2017 Wercker Holding BV
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// renderCtx carries the options plus the per-file state needed to render a
+// single .proto into its own output file: which proto is currently being
+// rendered, and which symbols from other protos it ended up referencing.
+type renderCtx struct {
+	opts *Options
+
+	// file is the name of the .proto currently being rendered.
+	file string
+
+	// syntax is the declared syntax ("proto2" or "proto3") of the file
+	// currently being rendered, needed to tell proto2's explicit `optional`
+	// apart from proto3's implicit one.
+	syntax string
+
+	// imports maps a qualified symbol name (e.g. "pkg$Foo") referenced from
+	// this file to the .proto it's declared in, for every symbol declared
+	// outside the current file.
+	imports map[string]string
+}
+
+// trackImport records that ctx's file references the given symbol, adding an
+// import edge if the symbol is declared in a different .proto.
+func trackImport(ctx *renderCtx, name string) {
+	var file string
+	if msg, ok := messageMap[name]; ok {
+		file = msg.File
+	} else if enum, ok := enumMap[name]; ok {
+		file = enum.File
+	} else {
+		return
+	}
+
+	if file != ctx.file {
+		ctx.imports[name] = file
+	}
+}
+
+// registerFile populates messageMap/enumMap with the owning file for every
+// symbol declared in f, ahead of rendering any file. This is pass one of the
+// two-pass design: it lets getFieldType resolve the file a referenced symbol
+// lives in even when that file hasn't been rendered yet.
+func registerFile(f *descriptor.FileDescriptorProto) {
+	ns := *f.Package + "$"
+	registerEnums(ns, f.EnumType, *f.Name)
+	registerMessages(ns, f.MessageType, *f.Name)
+}
+
+func registerEnums(namespace string, enums []*descriptor.EnumDescriptorProto, file string) {
+	for _, enum := range enums {
+		name := namespace + *enum.Name
+		enumMap[name] = &Enum{Name: name, File: file}
+	}
+}
+
+func registerMessages(namespace string, msgs []*descriptor.DescriptorProto, file string) {
+	for _, msg := range msgs {
+		name := namespace + *msg.Name
+		messageMap[name] = &Message{Name: name, File: file, IsMap: msg.GetOptions().GetMapEntry()}
+
+		nestedNS := name + "$"
+		registerEnums(nestedNS, msg.EnumType, file)
+		registerMessages(nestedNS, msg.NestedType, file)
+	}
+}
+
+// renderFile is pass two: it renders a single .proto into its own output
+// file, recording cross-file imports as it resolves field/method types.
+func renderFile(f *descriptor.FileDescriptorProto, opts *Options) *plugin.CodeGeneratorResponse_File {
+	ctx := &renderCtx{opts: opts, file: *f.Name, syntax: f.GetSyntax(), imports: map[string]string{}}
+
+	body := bytes.NewBuffer(nil)
+	ns := *f.Package + "$"
+	for _, enum := range f.EnumType {
+		emitEnumType(body, ns, enum, ctx)
+	}
+
+	for _, msg := range f.MessageType {
+		emitMessageType(body, ns, msg, ctx)
+	}
+
+	if opts.Services {
+		for _, svc := range f.Service {
+			emitService(body, ns, svc, ctx)
+		}
+	}
+
+	out := bytes.NewBuffer(nil)
+	fileHeaderTemplate.Execute(out, f)
+	out.WriteString(renderImports(ctx))
+	out.Write(body.Bytes())
+
+	return &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(outputFileName(*f.Name, opts)),
+		Content: proto.String(strings.TrimLeft(out.String(), "\n")),
+	}
+}
+
+// renderImports renders one `import type { ... } from '...';` statement per
+// file referenced by ctx, with symbols and files both sorted for
+// deterministic output.
+func renderImports(ctx *renderCtx) string {
+	if len(ctx.imports) == 0 {
+		return ""
+	}
+
+	namesByFile := map[string][]string{}
+	for name, file := range ctx.imports {
+		namesByFile[file] = append(namesByFile[file], name)
+	}
+
+	files := make([]string, 0, len(namesByFile))
+	for file := range namesByFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	buf := bytes.NewBuffer(nil)
+	for _, file := range files {
+		names := namesByFile[file]
+		sort.Strings(names)
+		fmt.Fprintf(buf, "import type { %s } from '%s';\n", strings.Join(names, ", "), relativeImportPath(ctx.file, file, ctx.opts))
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
+
+// outputFileName derives the generated filename for a .proto. With
+// `paths=source_relative` the directory structure of the proto is preserved;
+// otherwise the file is emitted flat, named after the proto's base name.
+func outputFileName(protoFile string, opts *Options) string {
+	trimmed := strings.TrimSuffix(protoFile, path.Ext(protoFile))
+	if opts.Paths == "source_relative" {
+		return trimmed + ".js.flow"
+	}
+
+	return path.Base(trimmed) + ".js.flow"
+}
+
+// relativeImportPath computes the Flow `import type ... from` path from the
+// output file for fromProto to the output file for toProto, always as a
+// relative './' or '../' path since that's what Flow's module resolution
+// needs. Import type handles cycles fine, so no cycle detection is needed.
+//
+// It must mirror outputFileName's layout: under the default `paths=import`
+// mode every file is emitted flat regardless of its proto's directory, so
+// the import is always a bare './toBase.js.flow' sibling reference; only
+// `paths=source_relative` preserves the proto's directory structure and
+// needs a real relative path between directories.
+func relativeImportPath(fromProto, toProto string, opts *Options) string {
+	toBase := strings.TrimSuffix(path.Base(toProto), path.Ext(toProto))
+	if opts.Paths != "source_relative" {
+		return "./" + toBase + ".js.flow"
+	}
+
+	from := path.Dir(fromProto)
+	to := path.Dir(toProto)
+
+	var fromParts, toParts []string
+	if from != "." {
+		fromParts = strings.Split(from, "/")
+	}
+	if to != "." {
+		toParts = strings.Split(to, "/")
+	}
+
+	i := 0
+	for i < len(fromParts) && i < len(toParts) && fromParts[i] == toParts[i] {
+		i++
+	}
+
+	rel := make([]string, 0, len(fromParts)-i+len(toParts)-i+1)
+	for range fromParts[i:] {
+		rel = append(rel, "..")
+	}
+	rel = append(rel, toParts[i:]...)
+	rel = append(rel, toBase)
+
+	p := strings.Join(rel, "/")
+	if !strings.HasPrefix(p, ".") {
+		p = "./" + p
+	}
+
+	return p + ".js.flow"
+}
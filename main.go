@@ -15,7 +15,6 @@
 package main
 
 import (
-	"bytes"
 	"io/ioutil"
 	"log"
 	"os"
@@ -29,16 +28,6 @@ import (
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
 )
 
-const flowFileHeader = `
-// @flow
-
-// ------------------------------------
-// Code generated by protoc-gen-flow
-// source: {{.GetName}}
-// THIS FILE IS AUTOMATICALLY GENERATED, DO NOT EDIT!
-// ------------------------------------
-`
-
 // Map qualified name to Message
 var messageMap map[string]*Message
 
@@ -61,36 +50,36 @@ func main() {
 		log.Fatalf("unable to parse protobuf: %v", err)
 	}
 
-	code := bytes.NewBuffer(nil)
-	code.WriteString(flowFileHeader)
+	opts := parseOptions(req.GetParameter())
 
 	for _, f := range req.ProtoFile {
-		ns := *f.Package + "$"
-		for _, enum := range f.EnumType {
-			emitEnumType(code, ns, enum)
-		}
+		registerFile(f)
+	}
 
-		for _, msg := range f.MessageType {
-			emitMessageType(code, ns, msg)
-		}
+	toGenerate := make(map[string]bool, len(req.FileToGenerate))
+	for _, name := range req.FileToGenerate {
+		toGenerate[name] = true
 	}
 
-	const outputFilename = "index.js"
+	var out []*plugin.CodeGeneratorResponse_File
+	for _, f := range req.ProtoFile {
+		if !toGenerate[*f.Name] {
+			continue
+		}
 
-	emitFiles([]*plugin.CodeGeneratorResponse_File{
-		{
-			Name:    proto.String(outputFilename),
-			Content: proto.String(strings.TrimLeft(code.String(), "\n")),
-		},
-	})
+		out = append(out, renderFile(f, opts))
+	}
+
+	emitFiles(out)
 }
 
-func emitEnumType(code io.Writer, namespace string, enum *descriptor.EnumDescriptorProto) error {
+func emitEnumType(code io.Writer, namespace string, enum *descriptor.EnumDescriptorProto, ctx *renderCtx) error {
 	name := namespace + *enum.Name
 
 	e := &Enum{
 		Name:   name,
 		Values: []string{},
+		File:   ctx.file,
 	}
 
 	for _, v := range enum.Value {
@@ -109,28 +98,75 @@ func emitEnumType(code io.Writer, namespace string, enum *descriptor.EnumDescrip
 	return nil
 }
 
-func emitMessageType(code io.Writer, namespace string, msg *descriptor.DescriptorProto) error {
+func emitMessageType(code io.Writer, namespace string, msg *descriptor.DescriptorProto, ctx *renderCtx) error {
 	name := namespace + *msg.Name
 
 	m := &Message{
 		Name:   name,
 		Fields: []*Field{},
 		IsMap:  msg.GetOptions().GetMapEntry(),
+		File:   ctx.file,
 	}
 
 	nestedNS := name+"$"
 	for _, enum := range msg.EnumType {
-		emitEnumType(code, nestedNS, enum)
+		emitEnumType(code, nestedNS, enum, ctx)
 	}
 
 	for _, nestedType := range msg.NestedType {
-		emitMessageType(code, nestedNS, nestedType)
+		emitMessageType(code, nestedNS, nestedType, ctx)
+	}
+
+	oneofs := make([]*Oneof, len(msg.OneofDecl))
+	for i, oneof := range msg.OneofDecl {
+		oneofs[i] = &Oneof{Name: name + "$" + toUpperCamel(*oneof.Name)}
 	}
 
 	for _, field := range msg.Field {
+		fieldType := getFieldType(name, field, ctx)
+		optional := fieldIsOptional(field, ctx.syntax, ctx.opts)
+
+		// A proto3 `optional` field is implemented as a one-member synthetic
+		// oneof (OneofIndex set, Proto3Optional true) purely so proto3 can
+		// track field presence; it's not a real oneof and must fall through
+		// to normal field handling so fieldIsOptional's GetProto3Optional()
+		// path actually renders `name?: T` instead of a bogus single-arm union.
+		if field.OneofIndex != nil && !field.GetProto3Optional() {
+			member := &OneofMember{}
+			for _, fieldName := range fieldNames(field, ctx.opts) {
+				member.Fields = append(member.Fields, &Field{
+					Name:     fieldName,
+					Type:     fieldType,
+					Optional: optional,
+				})
+			}
+			oneofs[*field.OneofIndex].Members = append(oneofs[*field.OneofIndex].Members, member)
+			continue
+		}
+
+		for _, fieldName := range fieldNames(field, ctx.opts) {
+			m.Fields = append(m.Fields, &Field{
+				Name:     fieldName,
+				Type:     fieldType,
+				Optional: optional,
+			})
+		}
+	}
+
+	for i, oneof := range oneofs {
+		// A oneof with no members is the synthetic oneof of a proto3
+		// `optional` field, whose field was rendered as a normal field above;
+		// skip it rather than emitting an empty union.
+		if len(oneof.Members) == 0 {
+			continue
+		}
+
+		oneofTemplate.Execute(code, oneof)
+
 		m.Fields = append(m.Fields, &Field{
-			Name: *field.Name,
-			Type: getFieldType(name, field),
+			Name:     toLowerCamel(*msg.OneofDecl[i].Name),
+			Type:     oneof.Name,
+			Optional: true,
 		})
 	}
 
@@ -144,6 +180,104 @@ func emitMessageType(code io.Writer, namespace string, msg *descriptor.Descripto
 	return nil
 }
 
+// toUpperCamel converts a snake_case proto identifier (e.g. a oneof name)
+// into UpperCamelCase so it matches the qualification scheme already used
+// for nested enums and messages (namespace$MessageName$OneofName).
+func toUpperCamel(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// toLowerCamel converts a snake_case proto identifier into lowerCamelCase,
+// matching jsonpb's canonical JSON field naming when a field has no explicit
+// JsonName.
+func toLowerCamel(name string) string {
+	camel := toUpperCamel(name)
+	if camel == "" {
+		return camel
+	}
+	return strings.ToLower(camel[:1]) + camel[1:]
+}
+
+// lowerFirst lowercases the first rune of s, e.g. "SayHello" -> "sayHello",
+// matching jsonpb's camelCase convention for method-ish identifiers.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// resolveQualifiedName turns a fully-qualified proto type name (as found on
+// FieldDescriptorProto.TypeName or MethodDescriptorProto.InputType/OutputType)
+// into the namespace$Name form used throughout the emitted Flow types.
+func resolveQualifiedName(typeName string) (string, bool) {
+	parts := strings.Split(typeName, ".")
+	if len(parts) < 2 {
+		return "", false
+	}
+	return strings.Join(parts[1:], "$"), true
+}
+
+// resolveMessageTypeName resolves a fully-qualified message type name (as
+// found on MethodDescriptorProto.InputType/OutputType) to the Flow type that
+// represents it, checking wellKnownTypes first the same way getFieldType does
+// for TYPE_MESSAGE fields, and recording a cross-file import for anything
+// that isn't a well-known type.
+func resolveMessageTypeName(ctx *renderCtx, typeName string) string {
+	if wkt, ok := wellKnownTypes[typeName]; ok {
+		return wkt(ctx.opts)
+	}
+
+	name, ok := resolveQualifiedName(typeName)
+	if !ok {
+		return "any"
+	}
+	trackImport(ctx, name)
+
+	return name
+}
+
+// emitService renders a gRPC service as a Flow interface, one method per RPC.
+// Streaming is expressed by wrapping the request/response in AsyncIterable:
+// unary -> Promise<Resp>, server-stream -> AsyncIterable<Resp>,
+// client-stream -> (requests: AsyncIterable<Req>), bidi -> both.
+func emitService(code io.Writer, namespace string, svc *descriptor.ServiceDescriptorProto, ctx *renderCtx) {
+	s := &Service{
+		Name:    namespace + *svc.Name + "$Service",
+		Methods: []*ServiceMethod{},
+	}
+
+	for _, method := range svc.Method {
+		reqType := resolveMessageTypeName(ctx, *method.InputType)
+		respType := resolveMessageTypeName(ctx, *method.OutputType)
+
+		params := "request: " + reqType
+		if method.GetClientStreaming() {
+			params = "requests: AsyncIterable<" + reqType + ">"
+		}
+
+		ret := "Promise<" + respType + ">"
+		if method.GetServerStreaming() {
+			ret = "AsyncIterable<" + respType + ">"
+		}
+
+		s.Methods = append(s.Methods, &ServiceMethod{
+			Name:   lowerFirst(*method.Name),
+			Params: params,
+			Return: ret,
+		})
+	}
+
+	serviceTemplate.Execute(code, s)
+}
+
 func emitFiles(out []*plugin.CodeGeneratorResponse_File) {
 	emitResp(&plugin.CodeGeneratorResponse{File: out})
 }
@@ -158,8 +292,39 @@ func emitResp(resp *plugin.CodeGeneratorResponse) {
 	}
 }
 
-func getFieldType(namespace string, field *descriptor.FieldDescriptorProto) string {
+// wellKnownTypes maps the fully-qualified name of a google.protobuf.* message
+// to a function producing the Flow type that matches how jsonpb serializes
+// it on the wire, so users get a single, coherent JSON-shape contract across
+// all of them. Most entries ignore opts; the ones whose wire shape is itself
+// configurable (timestamps and the 64-bit/bytes wrappers) consult it.
+var wellKnownTypes = map[string]func(*Options) string{
+	".google.protobuf.Timestamp": func(opts *Options) string { return opts.Timestamp },
+	".google.protobuf.Duration":  func(opts *Options) string { return "string" },
+	".google.protobuf.FieldMask": func(opts *Options) string { return "string" },
+	".google.protobuf.Empty":     func(opts *Options) string { return "{||}" },
+	".google.protobuf.Struct":    func(opts *Options) string { return "{ [key: string]: mixed }" },
+	".google.protobuf.Value": func(opts *Options) string {
+		return "null | number | string | boolean | Array<mixed> | { [key: string]: mixed }"
+	},
+	".google.protobuf.ListValue": func(opts *Options) string { return "Array<mixed>" },
+	".google.protobuf.Any":       func(opts *Options) string { return `{ "@type": string, [key: string]: mixed }` },
+
+	// Wrapper types unwrap to their underlying primitive, honoring the same
+	// options that control the equivalent plain field types.
+	".google.protobuf.DoubleValue": func(opts *Options) string { return "number" },
+	".google.protobuf.FloatValue":  func(opts *Options) string { return "number" },
+	".google.protobuf.Int32Value":  func(opts *Options) string { return "number" },
+	".google.protobuf.UInt32Value": func(opts *Options) string { return "number" },
+	".google.protobuf.Int64Value":  func(opts *Options) string { return opts.Int64 },
+	".google.protobuf.UInt64Value": func(opts *Options) string { return opts.Int64 },
+	".google.protobuf.BoolValue":   func(opts *Options) string { return "boolean" },
+	".google.protobuf.StringValue": func(opts *Options) string { return "string" },
+	".google.protobuf.BytesValue":  func(opts *Options) string { return opts.Bytes },
+}
+
+func getFieldType(namespace string, field *descriptor.FieldDescriptorProto, ctx *renderCtx) string {
 	ret := "any" // unknonwn
+	opts := ctx.opts
 
 	switch *field.Type {
 	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
@@ -176,40 +341,37 @@ func getFieldType(namespace string, field *descriptor.FieldDescriptorProto) stri
 		descriptor.FieldDescriptorProto_TYPE_SFIXED64,
 		descriptor.FieldDescriptorProto_TYPE_SINT64:
 		// javascript doesn't support 64bit ints
-		ret = "string"
+		ret = opts.Int64
 	case descriptor.FieldDescriptorProto_TYPE_BOOL:
 		ret = "boolean"
 	case descriptor.FieldDescriptorProto_TYPE_STRING:
 		ret = "string"
 	case descriptor.FieldDescriptorProto_TYPE_ENUM:
-		parts := strings.Split(*field.TypeName, ".")
-		if len(parts) < 2 {
+		name, ok := resolveQualifiedName(*field.TypeName)
+		if !ok {
 			ret = "any"
 			break
 		}
-		parts = parts[1:]
 
-		name := strings.Join(parts, "$")
-
-		_, ok := enumMap[name]
-		if !ok {
+		if _, ok := enumMap[name]; !ok {
 			panic(fmt.Sprintf("Enum '%v' not found in enum map", name))
 		}
 
 		ret = name
+		if opts.Enums == "number" {
+			ret = "number"
+		} else {
+			trackImport(ctx, name)
+		}
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		if *field.TypeName == ".google.protobuf.Timestamp" {
-			// Special case for handling timestamps
-			ret = "string"
+		if wkt, ok := wellKnownTypes[*field.TypeName]; ok {
+			ret = wkt(opts)
 		} else {
-			parts := strings.Split(*field.TypeName, ".")
-			if len(parts) < 2 {
+			name, ok := resolveQualifiedName(*field.TypeName)
+			if !ok {
 				ret = "any"
 				break
 			}
-			parts = parts[1:]
-
-			name := strings.Join(parts, "$")
 
 			msg, ok := messageMap[name]
 			if ok && msg.IsMap {
@@ -222,16 +384,24 @@ func getFieldType(namespace string, field *descriptor.FieldDescriptorProto) stri
 				// we return early to avoid appending array square braces.
 				return ret
 			}
+			trackImport(ctx, name)
 
 			ret = name
 		}
 	case descriptor.FieldDescriptorProto_TYPE_GROUP:
 		ret = "any"
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
-		ret = "any"
+		ret = opts.Bytes
 	}
 	if *field.Label == descriptor.FieldDescriptorProto_LABEL_REPEATED {
-		ret += "[]"
+		// A well-known type like Value renders as a bare top-level union
+		// ("null | number | ..."); appending "[]" would bind only to the
+		// last arm instead of the whole type, so wrap those in Array<...>.
+		if strings.Contains(ret, " | ") {
+			ret = "Array<" + ret + ">"
+		} else {
+			ret += "[]"
+		}
 	}
 
 	return ret
@@ -0,0 +1,140 @@
+//   Copyright 2017 Wercker Holding BV
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// Options holds the plugin parameters passed via `--flow_out=key=val,...:./out`.
+type Options struct {
+	Int64     string // string|number|bigint
+	Bytes     string // string|Uint8Array|any
+	Timestamp string // string|Date
+	Enums     string // string|number
+	Nullable  string // ""|proto2|proto3|all ("" honors each field's own label)
+	Paths     string // source_relative|import
+	Services  bool   // emit a Flow interface per gRPC service
+	Keys      string // json|proto|both
+}
+
+// parseOptions parses the comma/`=`-separated parameter string protoc passes
+// through on the command line into a typed Options struct, so the rest of
+// the emitter doesn't need to touch package-level globals or the raw string.
+func parseOptions(parameter string) *Options {
+	opts := &Options{
+		Int64:     "string",
+		Bytes:     "any",
+		Timestamp: "string",
+		Enums:     "string",
+		Paths:     "import",
+		Keys:      "json",
+	}
+
+	for _, pair := range strings.Split(parameter, ",") {
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key, val := kv[0], kv[1]
+		switch key {
+		case "int64":
+			opts.Int64 = val
+		case "bytes":
+			opts.Bytes = val
+		case "timestamp":
+			opts.Timestamp = val
+		case "enums":
+			opts.Enums = val
+		case "nullable":
+			opts.Nullable = val
+		case "paths":
+			opts.Paths = val
+		case "services":
+			opts.Services = val == "true"
+		case "keys":
+			opts.Keys = val
+		}
+	}
+
+	return opts
+}
+
+// fieldIsOptional reports whether a field should be rendered as `name?: T`
+// rather than `name: T`. Repeated fields are never optional: jsonpb always
+// defaults them to `[]`. By default both proto2 and proto3 optionality are
+// honored simultaneously straight off the field's own label and the syntax
+// of the file it's declared in — protoc reports LABEL_OPTIONAL for every
+// proto3 singular field, not just ones with the explicit `optional` keyword,
+// so proto3 optionality can only be read from GetProto3Optional(). `nullable`
+// narrows that to just one of the two, or forces every field optional with
+// "all".
+func fieldIsOptional(field *descriptor.FieldDescriptorProto, syntax string, opts *Options) bool {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+
+	switch opts.Nullable {
+	case "all":
+		return true
+	case "proto2":
+		return syntax != "proto3" && field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	case "proto3":
+		return field.GetProto3Optional()
+	default:
+		if syntax == "proto3" {
+			return field.GetProto3Optional()
+		}
+		return field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	}
+}
+
+// jsonFieldName returns the jsonpb-canonical JSON name for a field: the
+// descriptor's JsonName when the compiler provided one, else lowerCamelCase
+// of the proto field name.
+func jsonFieldName(field *descriptor.FieldDescriptorProto) string {
+	if name := field.GetJsonName(); name != "" {
+		return name
+	}
+	return toLowerCamel(field.GetName())
+}
+
+// fieldNames returns the property name(s) a field should be rendered under,
+// per the configured keys mode. "both" emits the field twice (json and
+// proto) for codebases that decode with preserveProtoFieldNames, collapsing
+// to one when the two names are identical.
+func fieldNames(field *descriptor.FieldDescriptorProto, opts *Options) []string {
+	protoName := field.GetName()
+	jsonName := jsonFieldName(field)
+
+	switch opts.Keys {
+	case "proto":
+		return []string{protoName}
+	case "both":
+		if jsonName == protoName {
+			return []string{protoName}
+		}
+		return []string{jsonName, protoName}
+	default: // "json"
+		return []string{jsonName}
+	}
+}
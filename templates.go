@@ -0,0 +1,51 @@
+//   Copyright 2017 Wercker Holding BV
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import "text/template"
+
+var fileHeaderTemplate = template.Must(template.New("fileHeader").Parse(`
+// @flow
+
+// ------------------------------------
+// Code generated by protoc-gen-flow
+// source: {{.GetName}}
+// THIS FILE IS AUTOMATICALLY GENERATED, DO NOT EDIT!
+// ------------------------------------
+`))
+
+var enumTemplate = template.Must(template.New("enum").Parse(`
+export type {{.Name}} =
+{{range .Values}}  | '{{.}}'
+{{end}};
+`))
+
+var messageTemplate = template.Must(template.New("message").Parse(`
+export type {{.Name}} = {|
+{{range .Fields}}  {{.Name}}{{if .Optional}}?{{end}}: {{.Type}},
+{{end}}|};
+`))
+
+var oneofTemplate = template.Must(template.New("oneof").Parse(`
+export type {{.Name}} =
+{{range .Members}}  | {| {{range .Fields}}{{.Name}}: {{.Type}}, {{end}}|}
+{{end}};
+`))
+
+var serviceTemplate = template.Must(template.New("service").Parse(`
+export interface {{.Name}} {
+{{range .Methods}}  {{.Name}}({{.Params}}): {{.Return}};
+{{end}}}
+`))
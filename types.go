@@ -0,0 +1,73 @@
+//   Copyright 2017 Wercker Holding BV
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+// Enum represents a proto enum rendered as a Flow string-literal union type.
+type Enum struct {
+	Name   string
+	Values []string
+
+	// File is the .proto this enum is declared in, used to decide whether a
+	// reference to it from another file needs an import.
+	File string
+}
+
+// Field represents a single message field rendered as a property of a Flow
+// object type.
+type Field struct {
+	Name     string
+	Type     string
+	Optional bool
+}
+
+// Message represents a proto message rendered as a Flow exact object type.
+type Message struct {
+	Name   string
+	Fields []*Field
+	IsMap  bool
+
+	// File is the .proto this message is declared in, used to decide whether
+	// a reference to it from another file needs an import.
+	File string
+}
+
+// Oneof represents a proto3 oneof, rendered as a Flow discriminated union.
+// Each member is normally a single-field exact object type, but under
+// keys=both a member carries one Field per name variant of the same proto
+// field, rendered as sibling properties rather than separate union arms.
+type Oneof struct {
+	Name    string
+	Members []*OneofMember
+}
+
+// OneofMember is one variant of a Oneof's union, holding every Field that
+// represents its underlying proto field (more than one only under keys=both).
+type OneofMember struct {
+	Fields []*Field
+}
+
+// ServiceMethod represents a single RPC method rendered as a member of a
+// Flow service interface.
+type ServiceMethod struct {
+	Name   string
+	Params string
+	Return string
+}
+
+// Service represents a gRPC service rendered as a Flow interface.
+type Service struct {
+	Name    string
+	Methods []*ServiceMethod
+}